@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type recordingSink struct {
+	records []Record
+	err     error
+}
+
+func (s *recordingSink) Write(rec Record) error {
+	s.records = append(s.records, rec)
+	return s.err
+}
+
+func TestLoggerRecord(t *testing.T) {
+	tests := []struct {
+		name     string
+		redact   bool
+		sourceIP string
+		want     Record
+	}{
+		{
+			name:     "records success with source IP",
+			sourceIP: "203.0.113.5",
+			want: Record{
+				ProviderID: "ldap", Username: "alice", SourceIP: "203.0.113.5",
+				Success: true, Groups: []string{"admins"},
+			},
+		},
+		{
+			name:     "redacts PII when enabled",
+			redact:   true,
+			sourceIP: "203.0.113.5",
+			want: Record{
+				ProviderID: "ldap", Username: "[redacted]", SourceIP: "[redacted]",
+				Success: true, Groups: []string{"admins"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink := &recordingSink{}
+			l := &Logger{Sink: sink, Redact: tt.redact}
+
+			ctx := context.Background()
+			if tt.sourceIP != "" {
+				ctx = WithSourceIP(ctx, tt.sourceIP)
+			}
+			l.Record(ctx, "ldap", "alice", true, FailureNone, []string{"admins"})
+
+			if len(sink.records) != 1 {
+				t.Fatalf("len(records) = %d, want 1", len(sink.records))
+			}
+			got := sink.records[0]
+			got.Time = tt.want.Time
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("record = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoggerRecordNilLoggerIsNoop(t *testing.T) {
+	var l *Logger
+	l.Record(context.Background(), "ldap", "alice", true, FailureNone, nil)
+}
+
+func TestLoggerRecordNilSinkIsNoop(t *testing.T) {
+	l := &Logger{}
+	l.Record(context.Background(), "ldap", "alice", true, FailureNone, nil)
+}
+
+func TestLoggerRecordSwallowsSinkError(t *testing.T) {
+	sink := &recordingSink{err: errors.New("disk full")}
+	l := &Logger{Sink: sink}
+	l.Record(context.Background(), "ldap", "alice", false, FailureBindFailed, nil)
+	if len(sink.records) != 1 {
+		t.Fatalf("len(records) = %d, want 1", len(sink.records))
+	}
+}
+
+func TestSourceIPFromContext(t *testing.T) {
+	if ip := SourceIPFromContext(context.Background()); ip != "" {
+		t.Errorf("SourceIPFromContext() = %q, want empty", ip)
+	}
+	ctx := WithSourceIP(context.Background(), "198.51.100.1")
+	if ip := SourceIPFromContext(ctx); ip != "198.51.100.1" {
+		t.Errorf("SourceIPFromContext() = %q, want %q", ip, "198.51.100.1")
+	}
+}
+
+func TestWriterSinkWritesJSONLines(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	if err := sink.Write(Record{ProviderID: "ldap", Username: "alice", Success: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.Write(Record{ProviderID: "ldap", Username: "bob", Success: false, FailureReason: FailureBindFailed}); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []Record
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		var rec Record
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	if len(records) != 2 {
+		t.Fatalf("len(records) = %d, want 2", len(records))
+	}
+	if records[1].FailureReason != FailureBindFailed {
+		t.Errorf("records[1].FailureReason = %q, want %q", records[1].FailureReason, FailureBindFailed)
+	}
+}
+
+func TestRotatingFileRotatesOnceOverMaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	rf, err := NewRotatingFile(path, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rf.Close()
+
+	if _, err := rf.Write([]byte("01234567890123456789")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Fatalf("expected rotated file %s.1 to exist: %s", path, err)
+	}
+}
+
+func TestEventPipelineFuncAdaptsToSink(t *testing.T) {
+	var got Record
+	var sink Sink = EventPipelineFunc(func(rec Record) error {
+		got = rec
+		return nil
+	})
+
+	if err := sink.Write(Record{ProviderID: "ldap", Username: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "alice" {
+		t.Errorf("Username = %q, want %q", got.Username, "alice")
+	}
+}
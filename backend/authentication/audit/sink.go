@@ -0,0 +1,110 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// WriterSink writes each Record to an io.Writer as a line of JSON. It can be
+// used directly with os.Stdout for stdout-JSON output, or wrapped around a
+// *RotatingFile for file-based sinks.
+type WriterSink struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewWriterSink returns a Sink that JSON-encodes each Record to w, one
+// record per line.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w}
+}
+
+// Write implements Sink.
+func (s *WriterSink) Write(rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enc := json.NewEncoder(s.w)
+	return enc.Encode(rec)
+}
+
+// RotatingFile is an io.Writer backed by a file on disk that rotates itself
+// to "<path>.1" once it exceeds MaxBytes, keeping a single prior generation.
+// It is intended to be wrapped in a WriterSink.
+type RotatingFile struct {
+	Path     string
+	MaxBytes int64
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+// NewRotatingFile opens (creating if necessary) path for appending and
+// returns a *RotatingFile that rotates once the file exceeds maxBytes.
+func NewRotatingFile(path string, maxBytes int64) (*RotatingFile, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open audit log file %q: %s", path, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("could not stat audit log file %q: %s", path, err)
+	}
+	return &RotatingFile{Path: path, MaxBytes: maxBytes, file: f, size: info.Size()}, nil
+}
+
+// Write implements io.Writer, rotating the underlying file first if the
+// write would exceed MaxBytes.
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.MaxBytes > 0 && r.size+int64(len(p)) > r.MaxBytes {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate renames the current file to "<path>.1", overwriting any previous
+// generation, and opens a fresh file in its place. The caller must hold r.mu.
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return fmt.Errorf("could not close audit log file %q for rotation: %s", r.Path, err)
+	}
+	if err := os.Rename(r.Path, r.Path+".1"); err != nil {
+		return fmt.Errorf("could not rotate audit log file %q: %s", r.Path, err)
+	}
+	f, err := os.OpenFile(r.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("could not reopen audit log file %q after rotation: %s", r.Path, err)
+	}
+	r.file = f
+	r.size = 0
+	return nil
+}
+
+// Close closes the underlying file.
+func (r *RotatingFile) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.file.Close()
+}
+
+// EventPipelineFunc adapts a function, typically one that publishes a Sensu
+// event, into a Sink.
+type EventPipelineFunc func(Record) error
+
+// Write implements Sink.
+func (f EventPipelineFunc) Write(rec Record) error {
+	return f(rec)
+}
@@ -0,0 +1,536 @@
+package activedirectory
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/sensu/sensu-go/backend/authentication/audit"
+	"github.com/sensu/sensu-go/backend/authentication/jwt"
+	"github.com/sensu/sensu-go/backend/authentication/providers"
+)
+
+// Type represents the type of the Active Directory authentication provider
+const Type = "activedirectory"
+
+func init() {
+	providers.Register(Type, func() providers.Provider { return &Provider{} })
+}
+
+// accountDisabled is bit 2 (0x0002) of the userAccountControl attribute
+const accountDisabled = 0x0002
+
+// Provider represents the Active Directory authentication provider. It is
+// modeled after the ldap.Provider, with defaults and lookup behaviors suited
+// to an AD directory rather than a generic LDAP one.
+type Provider struct {
+	corev2.ObjectMeta `json:"metadata"`
+	BindUsername      string
+	BindPassword      string
+	StartTLS          bool
+	URL               string
+
+	// UserBaseDN is the search base for user lookups. When empty, the
+	// default naming context advertised by the domain controller's RootDSE
+	// is used instead.
+	UserBaseDN    string
+	UserAttribute string
+	UserClass     string
+
+	// GroupBaseDN is the search base for group lookups, used only by the
+	// tokenGroups strategy. When empty, the default naming context is used.
+	GroupBaseDN    string
+	GroupAttribute string
+	GroupClass     string
+
+	// TrustedCAs, ServerName and SkipTLSVerify configure certificate
+	// verification for StartTLS and native LDAPS dials, mirroring the ldap
+	// provider.
+	TrustedCAs    []string
+	ServerName    string
+	SkipTLSVerify bool
+
+	// AuditLogger, when set, receives a structured record of every
+	// Authenticate and Refresh attempt against the domain controller. It is
+	// optional: a nil AuditLogger just means nothing records the attempt.
+	AuditLogger *audit.Logger
+}
+
+var timeout = 2 * time.Second
+
+// Authenticate authenticates a user against Active Directory
+func (p *Provider) Authenticate(ctx context.Context, username, password string) (claims *corev2.Claims, err error) {
+	var groups []string
+	reason := audit.FailureNone
+	defer func() {
+		p.AuditLogger.Record(ctx, p.Name(), username, err == nil, reason, groups)
+	}()
+
+	l, err := p.dial()
+	if err != nil {
+		reason = audit.FailureTLSError
+		return nil, err
+	}
+	defer l.Close()
+
+	groups, reason, err = p.authenticate(l, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err = p.claims(username, groups)
+	if err != nil {
+		reason = audit.FailureOther
+	}
+	return claims, err
+}
+
+// authenticate binds as the service account on l, looks up username,
+// rejects disabled accounts, verifies password by binding as the user, and
+// resolves group membership. It is separated from Authenticate, which owns
+// dialing and the audit record, so it can be exercised against a mock Conn.
+func (p *Provider) authenticate(l Conn, username, password string) (groups []string, reason audit.FailureReason, err error) {
+	if err := l.Bind(p.BindUsername, p.BindPassword); err != nil {
+		return nil, audit.FailureBindFailed, err
+	}
+
+	entry, err := p.getUserEntry(l, username)
+	if err != nil {
+		return nil, audit.FailureUserNotFound, err
+	}
+
+	if isAccountDisabled(entry) {
+		return nil, audit.FailureBindFailed, errors.New("account is disabled")
+	}
+
+	if err := l.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) && strings.Contains(err.Error(), "data 773") {
+			return nil, audit.FailureBindFailed, errors.New("password has expired")
+		}
+		return nil, audit.FailureBindFailed, err
+	}
+
+	groups, err = p.getGroups(l, entry)
+	if err != nil {
+		return nil, audit.FailureGroupLookupFailed, err
+	}
+	return groups, audit.FailureNone, nil
+}
+
+// Refresh renews the user claims with the provider claims
+func (p *Provider) Refresh(ctx context.Context, claims *corev2.Claims) (renewed *corev2.Claims, err error) {
+	username := claims.Provider.UserID
+	var groups []string
+	reason := audit.FailureNone
+	defer func() {
+		p.AuditLogger.Record(ctx, p.Name(), username, err == nil, reason, groups)
+	}()
+
+	l, err := p.dial()
+	if err != nil {
+		reason = audit.FailureTLSError
+		return nil, err
+	}
+	defer l.Close()
+
+	groups, reason, err = p.refresh(l, username)
+	if err != nil {
+		return nil, err
+	}
+
+	renewed, err = p.claims(username, groups)
+	if err != nil {
+		reason = audit.FailureOther
+	}
+	return renewed, err
+}
+
+// refresh binds as the service account on l, looks up username, rejects
+// disabled accounts, and resolves group membership. It is separated from
+// Refresh, which owns dialing and the audit record, so it can be exercised
+// against a mock Conn.
+func (p *Provider) refresh(l Conn, username string) (groups []string, reason audit.FailureReason, err error) {
+	if err := l.Bind(p.BindUsername, p.BindPassword); err != nil {
+		return nil, audit.FailureBindFailed, err
+	}
+
+	entry, err := p.getUserEntry(l, username)
+	if err != nil {
+		return nil, audit.FailureUserNotFound, err
+	}
+
+	if isAccountDisabled(entry) {
+		return nil, audit.FailureBindFailed, errors.New("account is disabled")
+	}
+
+	groups, err = p.getGroups(l, entry)
+	if err != nil {
+		return nil, audit.FailureGroupLookupFailed, err
+	}
+	return groups, audit.FailureNone, nil
+}
+
+// Conn is the subset of *ldap.Conn used by Provider, extracted so unit
+// tests can substitute a mock directory. *ldap.Conn satisfies this
+// interface.
+type Conn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// getUserEntry looks up the user entry, requesting userAccountControl and
+// memberOf. tokenGroups is deliberately not requested here: Active
+// Directory only computes that constructed attribute for a base-scope read
+// of the specific object being queried, not for a filtered subtree search,
+// so it is fetched separately by getTokenGroups once the user's DN is
+// known.
+func (p *Provider) getUserEntry(l Conn, username string) (*ldap.Entry, error) {
+	baseDN, err := p.userBaseDN(l)
+	if err != nil {
+		return nil, err
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf("(&(objectClass=%s)(%s=%s))", p.userClass(), p.userAttribute(), ldap.EscapeFilter(username)),
+		[]string{"dn", "userAccountControl", "memberOf"},
+		nil,
+	)
+
+	sr, err := l.Search(searchRequest)
+	if err != nil {
+		return nil, err
+	}
+	if len(sr.Entries) != 1 {
+		return nil, errors.New("user does not exist or too many entries returned")
+	}
+	return sr.Entries[0], nil
+}
+
+// isAccountDisabled reports whether bit 2 (ACCOUNTDISABLE) of
+// userAccountControl is set on entry.
+func isAccountDisabled(entry *ldap.Entry) bool {
+	uac := entry.GetAttributeValue("userAccountControl")
+	if uac == "" {
+		return false
+	}
+	var flags int
+	if _, err := fmt.Sscanf(uac, "%d", &flags); err != nil {
+		return false
+	}
+	return flags&accountDisabled != 0
+}
+
+// getGroups resolves the groups a user belongs to, preferring the memberOf
+// attribute already present on the user entry (walking nested groups), and
+// falling back to a dedicated base-scope read of the tokenGroups attribute
+// when memberOf is absent.
+func (p *Provider) getGroups(l Conn, entry *ldap.Entry) ([]string, error) {
+	if memberOf := entry.GetAttributeValues("memberOf"); len(memberOf) > 0 {
+		return p.walkMemberOf(l, memberOf, map[string]bool{})
+	}
+
+	tokenGroups, err := p.getTokenGroups(l, entry.DN)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokenGroups) > 0 {
+		return p.resolveTokenGroups(l, tokenGroups)
+	}
+
+	return nil, nil
+}
+
+// getTokenGroups reads the tokenGroups constructed attribute for dn. Active
+// Directory only populates tokenGroups on a base-scope read of the specific
+// object being queried, not on a filtered subtree search, so this issues a
+// dedicated search against the user's resolved DN.
+func (p *Provider) getTokenGroups(l Conn, dn string) ([][]byte, error) {
+	sr, err := l.Search(ldap.NewSearchRequest(
+		dn,
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"tokenGroups"},
+		nil,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("could not read tokenGroups for %s: %s", dn, err)
+	}
+	if len(sr.Entries) != 1 {
+		return nil, nil
+	}
+	return sr.Entries[0].GetRawAttributeValues("tokenGroups"), nil
+}
+
+// walkMemberOf recursively resolves nested group membership starting from a
+// set of group DNs, returning each group's GroupAttribute value (commonly
+// cn). seen prevents infinite loops on circular group membership.
+func (p *Provider) walkMemberOf(l Conn, dns []string, seen map[string]bool) ([]string, error) {
+	var groups []string
+	for _, dn := range dns {
+		if seen[dn] {
+			continue
+		}
+		seen[dn] = true
+
+		sr, err := l.Search(ldap.NewSearchRequest(
+			dn,
+			ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(objectClass=%s)", p.groupClass()),
+			[]string{p.groupAttribute(), "memberOf"},
+			nil,
+		))
+		if err != nil {
+			logger.Debugf("could not resolve group %s: %s", dn, err)
+			continue
+		}
+		for _, e := range sr.Entries {
+			groups = append(groups, e.GetAttributeValue(p.groupAttribute()))
+			nested, err := p.walkMemberOf(l, e.GetAttributeValues("memberOf"), seen)
+			if err != nil {
+				return nil, err
+			}
+			groups = append(groups, nested...)
+		}
+	}
+	return groups, nil
+}
+
+// resolveTokenGroups resolves the SIDs in the user's tokenGroups attribute to
+// group names by searching for objectSid in the group base.
+func (p *Provider) resolveTokenGroups(l Conn, sids [][]byte) ([]string, error) {
+	baseDN, err := p.groupBaseDN(l)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []string
+	for _, sid := range sids {
+		sr, err := l.Search(ldap.NewSearchRequest(
+			baseDN,
+			ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+			fmt.Sprintf("(objectSid=%s)", ldap.EscapeFilter(string(sid))),
+			[]string{p.groupAttribute()},
+			nil,
+		))
+		if err != nil {
+			logger.Debugf("could not resolve tokenGroups SID: %s", err)
+			continue
+		}
+		for _, e := range sr.Entries {
+			groups = append(groups, e.GetAttributeValue(p.groupAttribute()))
+		}
+	}
+	return groups, nil
+}
+
+// userBaseDN returns the configured UserBaseDN, or the domain's default
+// naming context when UserBaseDN is empty.
+func (p *Provider) userBaseDN(l Conn) (string, error) {
+	if p.UserBaseDN != "" {
+		return p.UserBaseDN, nil
+	}
+	return defaultNamingContext(l)
+}
+
+// groupBaseDN returns the configured GroupBaseDN, or the domain's default
+// naming context when GroupBaseDN is empty.
+func (p *Provider) groupBaseDN(l Conn) (string, error) {
+	if p.GroupBaseDN != "" {
+		return p.GroupBaseDN, nil
+	}
+	return defaultNamingContext(l)
+}
+
+// defaultNamingContext reads defaultNamingContext from the RootDSE.
+func defaultNamingContext(l Conn) (string, error) {
+	sr, err := l.Search(ldap.NewSearchRequest(
+		"",
+		ldap.ScopeBaseObject, ldap.NeverDerefAliases, 0, 0, false,
+		"(objectClass=*)",
+		[]string{"defaultNamingContext"},
+		nil,
+	))
+	if err != nil {
+		return "", fmt.Errorf("could not read RootDSE: %s", err)
+	}
+	if len(sr.Entries) != 1 {
+		return "", errors.New("RootDSE did not return a single entry")
+	}
+	dn := sr.Entries[0].GetAttributeValue("defaultNamingContext")
+	if dn == "" {
+		return "", errors.New("RootDSE did not advertise a defaultNamingContext")
+	}
+	return dn, nil
+}
+
+func (p *Provider) userAttribute() string {
+	if p.UserAttribute != "" {
+		return p.UserAttribute
+	}
+	return "sAMAccountName"
+}
+
+func (p *Provider) userClass() string {
+	if p.UserClass != "" {
+		return p.UserClass
+	}
+	return "user"
+}
+
+func (p *Provider) groupAttribute() string {
+	if p.GroupAttribute != "" {
+		return p.GroupAttribute
+	}
+	return "cn"
+}
+
+func (p *Provider) groupClass() string {
+	if p.GroupClass != "" {
+		return p.GroupClass
+	}
+	return "group"
+}
+
+// tlsConfig builds the *tls.Config used for both StartTLS and native LDAPS
+// dials, mirroring ldap.Provider's TLS hardening.
+func (p *Provider) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{ServerName: p.ServerName}
+
+	if p.SkipTLSVerify {
+		logger.Warning("activedirectory provider configured with SkipTLSVerify: TLS certificate verification is disabled")
+		cfg.InsecureSkipVerify = true
+		return cfg, nil
+	}
+
+	if len(p.TrustedCAs) == 0 {
+		return cfg, nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range p.TrustedCAs {
+		pem := []byte(ca)
+		if !strings.Contains(ca, "-----BEGIN") {
+			data, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("could not read TrustedCAs file %q: %s", ca, err)
+			}
+			pem = data
+		}
+		if ok := pool.AppendCertsFromPEM(pem); !ok {
+			return nil, fmt.Errorf("no certificates could be parsed from TrustedCAs entry %q", ca)
+		}
+	}
+	cfg.RootCAs = pool
+
+	return cfg, nil
+}
+
+// dial establishes a connection to the domain controller, using native LDAPS
+// for ldaps:// URLs and optionally upgrading to TLS via StartTLS otherwise.
+func (p *Provider) dial() (*ldap.Conn, error) {
+	tlsConfig, err := p.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []ldap.DialOpt{ldap.DialWithDialer(&net.Dialer{Timeout: timeout})}
+	if strings.HasPrefix(p.URL, "ldaps://") {
+		opts = append(opts, ldap.DialWithTLSConfig(tlsConfig))
+	}
+
+	l, err := ldap.DialURL(p.URL, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.StartTLS {
+		if err := l.StartTLS(tlsConfig); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
+}
+
+func (p *Provider) claims(username string, groups []string) (*corev2.Claims, error) {
+	user := &corev2.User{
+		Username: username,
+		Groups:   groups,
+		Disabled: false,
+	}
+	claims, err := jwt.NewClaims(user)
+	claims.Provider = corev2.AuthProviderClaims{
+		ProviderID: p.Name(),
+		UserID:     username,
+	}
+	return claims, err
+}
+
+// Name returns the operator-assigned name of this provider instance,
+// falling back to Type when none was set. This lets multiple activedirectory
+// providers (e.g. two separate domains) coexist in a Chain under distinct
+// names.
+func (p *Provider) Name() string {
+	if p.ObjectMeta.Name != "" {
+		return p.ObjectMeta.Name
+	}
+	return Type
+}
+
+// Type returns the provider type
+func (p *Provider) Type() string {
+	return Type
+}
+
+// GetObjectMeta returns the object metadata for the resource.
+func (p *Provider) GetObjectMeta() corev2.ObjectMeta {
+	return p.ObjectMeta
+}
+
+// SetObjectMeta sets the object metadata for the resource.
+func (p *Provider) SetObjectMeta(meta corev2.ObjectMeta) {
+	p.ObjectMeta = meta
+}
+
+// SetNamespace sets the namespace of the resource.
+func (p *Provider) SetNamespace(namespace string) {
+	p.Namespace = namespace
+}
+
+// StorePrefix gives the path prefix to this resource in the store
+func (p *Provider) StorePrefix() string {
+	return ""
+}
+
+// RBACName describes the name of the resource for RBAC purposes.
+func (p *Provider) RBACName() string {
+	return ""
+}
+
+// URIPath gives the path to the resource, e.g. /checks/checkname
+func (p *Provider) URIPath() string {
+	return ""
+}
+
+// Validate checks if the fields in the resource are valid.
+func (p *Provider) Validate() error {
+	if p.ObjectMeta.Name == "" {
+		p.ObjectMeta.Name = Type
+	}
+	if _, err := p.tlsConfig(); err != nil {
+		return fmt.Errorf("invalid activedirectory provider TLS configuration: %s", err)
+	}
+	return nil
+}
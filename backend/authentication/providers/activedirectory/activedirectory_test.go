@@ -0,0 +1,353 @@
+package activedirectory
+
+import (
+	"errors"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/sensu/sensu-go/backend/authentication/audit"
+)
+
+// mockConn is a minimal Conn used to unit test Provider without a live
+// domain controller. searches records every request issued against it, and
+// responses is consulted in order, one entry per Search call. binds is
+// consulted in the same way, one error per Bind call, in case callers need
+// to fail a specific bind (e.g. the user bind but not the service account
+// bind) without failing every bind.
+type mockConn struct {
+	searches  []*ldap.SearchRequest
+	responses []*ldap.SearchResult
+	err       error
+
+	binds   []error
+	bindDNs []string
+}
+
+func (c *mockConn) Bind(username, password string) error {
+	c.bindDNs = append(c.bindDNs, username)
+	i := len(c.bindDNs) - 1
+	if i >= len(c.binds) {
+		return nil
+	}
+	return c.binds[i]
+}
+
+func (c *mockConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.searches = append(c.searches, req)
+	if c.err != nil {
+		return nil, c.err
+	}
+	i := len(c.searches) - 1
+	if i >= len(c.responses) {
+		return &ldap.SearchResult{}, nil
+	}
+	return c.responses[i], nil
+}
+
+func (c *mockConn) Close() {}
+
+func newEntry(dn string, attrs map[string][]string) *ldap.Entry {
+	var eas []*ldap.EntryAttribute
+	for name, values := range attrs {
+		eas = append(eas, &ldap.EntryAttribute{Name: name, Values: values})
+	}
+	return &ldap.Entry{DN: dn, Attributes: eas}
+}
+
+func TestIsAccountDisabled(t *testing.T) {
+	tests := []struct {
+		name string
+		uac  string
+		want bool
+	}{
+		{name: "enabled account", uac: "512", want: false},
+		{name: "disabled account", uac: "514", want: true},
+		{name: "missing attribute", uac: "", want: false},
+		{name: "unparseable attribute", uac: "not-a-number", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			entry := newEntry("cn=user,dc=example,dc=com", map[string][]string{"userAccountControl": {tc.uac}})
+			if got := isAccountDisabled(entry); got != tc.want {
+				t.Errorf("isAccountDisabled() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestWalkMemberOfResolvesNestedGroupsAndBreaksCycles(t *testing.T) {
+	p := &Provider{}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=child,dc=example,dc=com", map[string][]string{
+				"cn":       {"child"},
+				"memberOf": {"cn=parent,dc=example,dc=com"},
+			})}},
+			{Entries: []*ldap.Entry{newEntry("cn=parent,dc=example,dc=com", map[string][]string{
+				"cn":       {"parent"},
+				"memberOf": {"cn=child,dc=example,dc=com"}, // cycle back to child
+			})}},
+		},
+	}
+
+	groups, err := p.walkMemberOf(conn, []string{"cn=child,dc=example,dc=com"}, map[string]bool{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]bool{"child": true, "parent": true}
+	if len(groups) != len(want) {
+		t.Fatalf("groups = %v, want two entries covering %v", groups, want)
+	}
+	for _, g := range groups {
+		if !want[g] {
+			t.Errorf("unexpected group %q in %v", g, groups)
+		}
+	}
+	if len(conn.searches) != 2 {
+		t.Errorf("len(searches) = %d, want 2 (cycle should not re-search child)", len(conn.searches))
+	}
+}
+
+func TestGetGroupsPrefersMemberOf(t *testing.T) {
+	p := &Provider{}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=admins,dc=example,dc=com", map[string][]string{"cn": {"admins"}})}},
+		},
+	}
+	entry := newEntry("cn=alice,dc=example,dc=com", map[string][]string{"memberOf": {"cn=admins,dc=example,dc=com"}})
+
+	groups, err := p.getGroups(conn, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0] != "admins" {
+		t.Errorf("groups = %v, want [admins]", groups)
+	}
+	if len(conn.searches) != 1 {
+		t.Fatalf("len(searches) = %d, want 1; getGroups should not also query tokenGroups when memberOf is present", len(conn.searches))
+	}
+}
+
+func TestGetGroupsFallsBackToTokenGroupsWithBaseScopeRead(t *testing.T) {
+	p := &Provider{GroupBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			// getTokenGroups: base-scope read of the user's own DN.
+			{Entries: []*ldap.Entry{{
+				DN: "cn=alice,dc=example,dc=com",
+				Attributes: []*ldap.EntryAttribute{
+					{Name: "tokenGroups", ByteValues: [][]byte{[]byte("sid-1")}},
+				},
+			}}},
+			// resolveTokenGroups: subtree search for the resolved SID.
+			{Entries: []*ldap.Entry{newEntry("cn=admins,dc=example,dc=com", map[string][]string{"cn": {"admins"}})}},
+		},
+	}
+	entry := newEntry("cn=alice,dc=example,dc=com", nil)
+
+	groups, err := p.getGroups(conn, entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0] != "admins" {
+		t.Errorf("groups = %v, want [admins]", groups)
+	}
+
+	if len(conn.searches) != 2 {
+		t.Fatalf("len(searches) = %d, want 2", len(conn.searches))
+	}
+	tokenGroupsSearch := conn.searches[0]
+	if tokenGroupsSearch.Scope != ldap.ScopeBaseObject {
+		t.Errorf("tokenGroups search scope = %d, want ScopeBaseObject; AD only populates tokenGroups on a base-scope read", tokenGroupsSearch.Scope)
+	}
+	if tokenGroupsSearch.BaseDN != entry.DN {
+		t.Errorf("tokenGroups search base = %q, want the user's own DN %q", tokenGroupsSearch.BaseDN, entry.DN)
+	}
+}
+
+func TestGetUserEntryDoesNotRequestTokenGroupsOnSubtreeSearch(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", nil)}},
+		},
+	}
+
+	if _, err := p.getUserEntry(conn, "alice"); err != nil {
+		t.Fatal(err)
+	}
+
+	req := conn.searches[0]
+	for _, attr := range req.Attributes {
+		if attr == "tokenGroups" {
+			t.Error("getUserEntry should not request tokenGroups on a filtered subtree search; AD never populates it there")
+		}
+	}
+}
+
+func TestGetTokenGroupsErrorIsWrapped(t *testing.T) {
+	p := &Provider{}
+	conn := &mockConn{err: errors.New("directory unavailable")}
+
+	if _, err := p.getTokenGroups(conn, "cn=alice,dc=example,dc=com"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestProviderNameDefaultsToTypeButHonorsObjectMeta(t *testing.T) {
+	unnamed := Provider{}
+	if err := unnamed.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if unnamed.Name() != Type {
+		t.Errorf("Name() = %q, want %q", unnamed.Name(), Type)
+	}
+
+	named := Provider{}
+	named.ObjectMeta.Name = "ad-corp"
+	if err := named.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if named.Name() != "ad-corp" {
+		t.Errorf("Name() = %q, want %q; Validate must not overwrite an operator-assigned name", named.Name(), "ad-corp")
+	}
+}
+
+func TestAuthenticateRejectsServiceAccountBindFailure(t *testing.T) {
+	p := &Provider{}
+	conn := &mockConn{binds: []error{errors.New("invalid credentials")}}
+
+	_, reason, err := p.authenticate(conn, "alice", "hunter2")
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	if reason != audit.FailureBindFailed {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureBindFailed)
+	}
+	if len(conn.searches) != 0 {
+		t.Error("should not look up the user when the service account bind fails")
+	}
+}
+
+func TestAuthenticateRejectsDisabledAccount(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", map[string][]string{
+				"userAccountControl": {"514"},
+			})}},
+		},
+	}
+
+	_, reason, err := p.authenticate(conn, "alice", "hunter2")
+	if err == nil || err.Error() != "account is disabled" {
+		t.Fatalf("err = %v, want \"account is disabled\"", err)
+	}
+	if reason != audit.FailureBindFailed {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureBindFailed)
+	}
+	if len(conn.bindDNs) != 1 {
+		t.Error("should not attempt the password bind for a disabled account")
+	}
+}
+
+func TestAuthenticateRejectsExpiredPassword(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", map[string][]string{
+				"userAccountControl": {"512"},
+			})}},
+		},
+		binds: []error{
+			nil, // service account bind succeeds
+			&ldap.Error{ResultCode: ldap.LDAPResultInvalidCredentials, Err: errors.New("80090308: AcceptSecurityContext error, data 773, v1db1")},
+		},
+	}
+
+	_, reason, err := p.authenticate(conn, "alice", "wrong")
+	if err == nil || err.Error() != "password has expired" {
+		t.Fatalf("err = %v, want \"password has expired\"", err)
+	}
+	if reason != audit.FailureBindFailed {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureBindFailed)
+	}
+}
+
+func TestAuthenticateSucceeds(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", map[string][]string{
+				"userAccountControl": {"512"},
+				"memberOf":           {"cn=admins,dc=example,dc=com"},
+			})}},
+			{Entries: []*ldap.Entry{newEntry("cn=admins,dc=example,dc=com", map[string][]string{"cn": {"admins"}})}},
+		},
+	}
+
+	groups, reason, err := p.authenticate(conn, "alice", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != audit.FailureNone {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureNone)
+	}
+	if len(groups) != 1 || groups[0] != "admins" {
+		t.Errorf("groups = %v, want [admins]", groups)
+	}
+	if len(conn.bindDNs) != 2 || conn.bindDNs[1] != "cn=alice,dc=example,dc=com" {
+		t.Errorf("bindDNs = %v, want the service account then the user's DN", conn.bindDNs)
+	}
+}
+
+func TestRefreshRejectsDisabledAccount(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", map[string][]string{
+				"userAccountControl": {"514"},
+			})}},
+		},
+	}
+
+	_, reason, err := p.refresh(conn, "alice")
+	if err == nil || err.Error() != "account is disabled" {
+		t.Fatalf("err = %v, want \"account is disabled\"", err)
+	}
+	if reason != audit.FailureBindFailed {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureBindFailed)
+	}
+}
+
+func TestRefreshSucceeds(t *testing.T) {
+	p := &Provider{UserBaseDN: "dc=example,dc=com"}
+	conn := &mockConn{
+		responses: []*ldap.SearchResult{
+			{Entries: []*ldap.Entry{newEntry("cn=alice,dc=example,dc=com", map[string][]string{
+				"userAccountControl": {"512"},
+				"memberOf":           {"cn=admins,dc=example,dc=com"},
+			})}},
+			{Entries: []*ldap.Entry{newEntry("cn=admins,dc=example,dc=com", map[string][]string{"cn": {"admins"}})}},
+		},
+	}
+
+	groups, reason, err := p.refresh(conn, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason != audit.FailureNone {
+		t.Errorf("reason = %q, want %q", reason, audit.FailureNone)
+	}
+	if len(groups) != 1 || groups[0] != "admins" {
+		t.Errorf("groups = %v, want [admins]", groups)
+	}
+	// refresh never verifies a password, so it should only bind once, as
+	// the service account.
+	if len(conn.bindDNs) != 1 {
+		t.Errorf("bindDNs = %v, want exactly one service account bind", conn.bindDNs)
+	}
+}
@@ -4,33 +4,60 @@ import (
 	"context"
 
 	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/sensu/sensu-go/backend/authentication/audit"
 	"github.com/sensu/sensu-go/backend/authentication/jwt"
+	"github.com/sensu/sensu-go/backend/authentication/providers"
 )
 
 // Type represents the type of the basic authentication provider
 const Type = "allowall"
 
+func init() {
+	providers.Register(Type, func() providers.Provider { return &Provider{} })
+}
+
 // Provider represents the allowall internal authentication provider
 type Provider struct {
 	corev2.ObjectMeta `json:"metadata"`
+
+	// AuditLogger, when set, receives a structured record of every request
+	// this provider grants cluster-admin to. Given how broad that grant is,
+	// operators who enable allowall are strongly encouraged to set one; a nil
+	// AuditLogger is accepted but simply drops the records.
+	AuditLogger *audit.Logger
 }
 
+// allowallGroups is the fixed group membership granted to every user.
+var allowallGroups = []string{"cluster-admins"}
+
 // Authenticate allow all users to authenticate as god
 func (p *Provider) Authenticate(ctx context.Context, username, password string) (*corev2.Claims, error) {
-	logger.Debugf("Authenticating: %s", username)
-	return p.claims(username)
+	claims, err := p.claims(username)
+	p.AuditLogger.Record(ctx, p.Name(), username, err == nil, auditFailureReason(err), allowallGroups)
+	return claims, err
 }
 
 // Refresh renews the user claims with the provider claims
 func (p *Provider) Refresh(ctx context.Context, claims *corev2.Claims) (*corev2.Claims, error) {
-	logger.Debugf("Refreshing: %s", claims.Provider.UserID)
-	return p.claims(claims.Provider.UserID)
+	renewed, err := p.claims(claims.Provider.UserID)
+	p.AuditLogger.Record(ctx, p.Name(), claims.Provider.UserID, err == nil, auditFailureReason(err), allowallGroups)
+	return renewed, err
+}
+
+// auditFailureReason categorizes a claims construction error for the audit
+// log; allowall never fails to authenticate, so the only possible failure
+// is jwt.NewClaims itself erroring.
+func auditFailureReason(err error) audit.FailureReason {
+	if err == nil {
+		return audit.FailureNone
+	}
+	return audit.FailureOther
 }
 
 func (p *Provider) claims(username string) (*corev2.Claims, error) {
 	user := &corev2.User{
 		Username: username,
-		Groups:   []string{"cluster-admins"},
+		Groups:   allowallGroups,
 		Disabled: false,
 	}
 	claims, err := jwt.NewClaims(user)
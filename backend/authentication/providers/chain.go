@@ -0,0 +1,185 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+// Result is the outcome of a successful Authenticate or Refresh call,
+// recording which provider in the chain produced it.
+type Result struct {
+	Claims       *corev2.Claims
+	ProviderName string
+}
+
+// insecureTypes lists provider types that must be explicitly allowed by the
+// operator before they can be added to a Chain, so they cannot be enabled by
+// accident in production. allowall is gated this way: it authenticates
+// anyone as a cluster admin.
+var insecureTypes = map[string]bool{
+	"allowall": true,
+}
+
+// RequiresExplicitEnable reports whether providerType must be explicitly
+// allowed (e.g. via a backend startup flag) before it can be added to a
+// Chain.
+func RequiresExplicitEnable(providerType string) bool {
+	return insecureTypes[providerType]
+}
+
+// entry pairs a configured provider with whether it is currently enabled.
+type entry struct {
+	provider Provider
+	enabled  bool
+}
+
+// Chain is an in-memory ordered list of configured authentication providers.
+// It does not persist anything itself; Handler keeps a Chain in sync with a
+// Store so that configuration survives a backend restart. On Authenticate, each enabled provider is tried in order until
+// one succeeds, so an operator can for example try ldap first and fall back
+// to an internal basic provider.
+type Chain struct {
+	mu      sync.RWMutex
+	entries []*entry
+
+	// AuditFunc, when set, is invoked after every configuration change made
+	// to this chain (add, enable/disable, remove) with the action taken and
+	// the name of the affected provider. It is guarded by mu so concurrent
+	// chains never stomp each other's audit sink.
+	AuditFunc func(action, providerName string)
+}
+
+// NewChain returns an empty, ready to use Chain.
+func NewChain() *Chain {
+	return &Chain{}
+}
+
+// audit invokes c.AuditFunc, if set. The caller must hold c.mu.
+func (c *Chain) audit(action, providerName string) {
+	if c.AuditFunc != nil {
+		c.AuditFunc(action, providerName)
+	}
+}
+
+// Add appends p to the chain. allowInsecure must be true to add a provider
+// of a type that RequiresExplicitEnable reports true for.
+func (c *Chain) Add(p Provider, enabled, allowInsecure bool) error {
+	if RequiresExplicitEnable(p.Type()) && !allowInsecure {
+		return fmt.Errorf("provider type %q must be explicitly enabled to be added to the chain", p.Type())
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = append(c.entries, &entry{provider: p, enabled: enabled})
+	c.audit("add", p.Name())
+	return nil
+}
+
+// SetEnabled toggles whether the named provider participates in
+// Authenticate/Refresh, returning false if no provider by that name was
+// found.
+func (c *Chain) SetEnabled(name string, enabled bool) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.entries {
+		if e.provider.Name() == name {
+			e.enabled = enabled
+			action := "disable"
+			if enabled {
+				action = "enable"
+			}
+			c.audit(action, name)
+			return true
+		}
+	}
+	return false
+}
+
+// Remove deletes the named provider from the chain, returning false if no
+// provider by that name was found.
+func (c *Chain) Remove(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, e := range c.entries {
+		if e.provider.Name() == name {
+			c.entries = append(c.entries[:i], c.entries[i+1:]...)
+			c.audit("remove", name)
+			return true
+		}
+	}
+	return false
+}
+
+// Providers returns the chain's providers in order.
+func (c *Chain) Providers() []Provider {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	providers := make([]Provider, len(c.entries))
+	for i, e := range c.entries {
+		providers[i] = e.provider
+	}
+	return providers
+}
+
+// ErrNoProviders is returned by Authenticate and Refresh when the chain has
+// no enabled providers to try.
+var ErrNoProviders = errors.New("no enabled authentication providers configured")
+
+// Authenticate tries each enabled provider in order, returning the claims
+// from the first one that successfully authenticates username/password.
+func (c *Chain) Authenticate(ctx context.Context, username, password string) (*Result, error) {
+	c.mu.RLock()
+	entries := make([]*entry, len(c.entries))
+	copy(entries, c.entries)
+	c.mu.RUnlock()
+
+	var lastErr error
+	tried := false
+	for _, e := range entries {
+		if !e.enabled {
+			continue
+		}
+		tried = true
+		claims, err := e.provider.Authenticate(ctx, username, password)
+		if err == nil {
+			return &Result{Claims: claims, ProviderName: e.provider.Name()}, nil
+		}
+		lastErr = err
+	}
+	if !tried {
+		return nil, ErrNoProviders
+	}
+	return nil, lastErr
+}
+
+// Refresh renews claims using the provider named in claims.Provider.ProviderID,
+// so a token minted by one provider in the chain is always refreshed by
+// that same provider.
+func (c *Chain) Refresh(ctx context.Context, claims *corev2.Claims) (*Result, error) {
+	providerName := claims.Provider.ProviderID
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, e := range c.entries {
+		if e.provider.Name() != providerName {
+			continue
+		}
+		if !e.enabled {
+			return nil, fmt.Errorf("authentication provider %q is disabled", providerName)
+		}
+		newClaims, err := e.provider.Refresh(ctx, claims)
+		if err != nil {
+			return nil, err
+		}
+		return &Result{Claims: newClaims, ProviderName: providerName}, nil
+	}
+	return nil, fmt.Errorf("authentication provider %q is not configured", providerName)
+}
@@ -0,0 +1,140 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestChainAuthenticateTriesInOrder(t *testing.T) {
+	c := NewChain()
+	first := &fakeProvider{name: "first", authErr: errors.New("bind failed")}
+	second := &fakeProvider{name: "second"}
+
+	if err := c.Add(first, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Add(second, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := c.Authenticate(context.Background(), "eric", "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ProviderName != "second" {
+		t.Errorf("ProviderName = %q, want %q", result.ProviderName, "second")
+	}
+}
+
+func TestChainSkipsDisabledProviders(t *testing.T) {
+	c := NewChain()
+	p := &fakeProvider{name: "disabled"}
+	if err := c.Add(p, false, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Authenticate(context.Background(), "eric", "hunter2"); err != ErrNoProviders {
+		t.Errorf("err = %v, want %v", err, ErrNoProviders)
+	}
+}
+
+func TestChainSetEnabledAndRemove(t *testing.T) {
+	c := NewChain()
+	p := &fakeProvider{name: "toggled"}
+	if err := c.Add(p, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if !c.SetEnabled("toggled", false) {
+		t.Fatal("SetEnabled() = false, want true")
+	}
+	if _, err := c.Authenticate(context.Background(), "eric", "hunter2"); err != ErrNoProviders {
+		t.Errorf("err = %v, want %v", err, ErrNoProviders)
+	}
+
+	if !c.Remove("toggled") {
+		t.Fatal("Remove() = false, want true")
+	}
+	if len(c.Providers()) != 0 {
+		t.Errorf("len(Providers()) = %d, want 0", len(c.Providers()))
+	}
+}
+
+func TestChainRejectsInsecureProviderWithoutExplicitEnable(t *testing.T) {
+	c := NewChain()
+	p := &fakeProvider{name: "everyone"}
+	p.name = "everyone"
+
+	// borrow the "allowall" gate by asserting on the real type directly
+	if !RequiresExplicitEnable("allowall") {
+		t.Fatal("expected allowall to require explicit enable")
+	}
+
+	// a fake provider typed as allowall should be rejected the same way
+	insecure := &insecureFakeProvider{fakeProvider: p}
+	if err := c.Add(insecure, true, false); err == nil {
+		t.Fatal("expected Add() to reject an insecure provider without allowInsecure")
+	}
+	if err := c.Add(insecure, true, true); err != nil {
+		t.Fatalf("expected Add() to accept an insecure provider with allowInsecure: %s", err)
+	}
+}
+
+// insecureFakeProvider reports its Type() as "allowall" so tests can
+// exercise the RequiresExplicitEnable gate without importing the real
+// allowall package.
+type insecureFakeProvider struct {
+	*fakeProvider
+}
+
+func (p *insecureFakeProvider) Type() string { return "allowall" }
+
+func TestChainAuditsConfigurationChanges(t *testing.T) {
+	var actions []string
+	c := NewChain()
+	c.AuditFunc = func(action, name string) {
+		actions = append(actions, action+":"+name)
+	}
+
+	p := &fakeProvider{name: "audited"}
+	if err := c.Add(p, true, false); err != nil {
+		t.Fatal(err)
+	}
+	c.SetEnabled("audited", false)
+	c.Remove("audited")
+
+	want := []string{"add:audited", "disable:audited", "remove:audited"}
+	if len(actions) != len(want) {
+		t.Fatalf("actions = %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("actions[%d] = %q, want %q", i, actions[i], want[i])
+		}
+	}
+}
+
+func TestChainAuditFuncIsPerChain(t *testing.T) {
+	var firstActions, secondActions []string
+
+	first := NewChain()
+	first.AuditFunc = func(action, name string) { firstActions = append(firstActions, action+":"+name) }
+
+	second := NewChain()
+	second.AuditFunc = func(action, name string) { secondActions = append(secondActions, action+":"+name) }
+
+	if err := first.Add(&fakeProvider{name: "one"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := second.Add(&fakeProvider{name: "two"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(firstActions) != 1 || firstActions[0] != "add:one" {
+		t.Errorf("firstActions = %v, want [add:one]", firstActions)
+	}
+	if len(secondActions) != 1 || secondActions[0] != "add:two" {
+		t.Errorf("secondActions = %v, want [add:two]", secondActions)
+	}
+}
@@ -0,0 +1,159 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Handler serves the /authproviders CRUD API: it persists ProviderConfigs to
+// a Store and keeps an in-memory Chain in sync so that adds, updates,
+// enables/disables, and removes take effect immediately, without a backend
+// restart.
+type Handler struct {
+	Store Store
+	Chain *Chain
+
+	// AllowInsecure is passed through to Chain.Add, gating provider types
+	// (e.g. allowall) that must be explicitly enabled by the operator.
+	AllowInsecure bool
+}
+
+// NewHandler returns a Handler serving store and keeping chain in sync.
+func NewHandler(store Store, chain *Chain) *Handler {
+	return &Handler{Store: store, Chain: chain}
+}
+
+// LoadAll populates chain from every ProviderConfig currently in store, so a
+// restarted backend resumes with the providers operators configured through
+// the CRUD API. Providers whose type is no longer registered, or whose type
+// requires explicit enablement that AllowInsecure doesn't grant, are skipped
+// rather than aborting the whole load.
+func (h *Handler) LoadAll(ctx context.Context) error {
+	cfgs, err := h.Store.ListProviders(ctx)
+	if err != nil {
+		return err
+	}
+	for _, cfg := range cfgs {
+		p, err := build(cfg)
+		if err != nil {
+			logger.Warningf("could not load authentication provider %q: %s", cfg.Name, err)
+			continue
+		}
+		if err := h.Chain.Add(p, cfg.Enabled, h.AllowInsecure); err != nil {
+			logger.Warningf("could not add authentication provider %q to chain: %s", cfg.Name, err)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP implements http.Handler, routing requests under /authproviders.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/authproviders")
+	name := strings.Trim(path, "/")
+
+	switch {
+	case name == "" && r.Method == http.MethodGet:
+		h.list(w, r)
+	case name == "" && r.Method == http.MethodPost:
+		h.createOrUpdate(w, r)
+	case name != "" && r.Method == http.MethodGet:
+		h.get(w, r, name)
+	case name != "" && r.Method == http.MethodPut:
+		h.createOrUpdate(w, r)
+	case name != "" && r.Method == http.MethodDelete:
+		h.delete(w, r, name)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) list(w http.ResponseWriter, r *http.Request) {
+	cfgs, err := h.Store.ListProviders(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cfgs)
+}
+
+func (h *Handler) get(w http.ResponseWriter, r *http.Request, name string) {
+	cfg, err := h.Store.GetProvider(r.Context(), name)
+	if errors.Is(err, ErrProviderNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// createOrUpdate persists cfg and reconciles the Chain: an existing provider
+// by that name is removed first so Add reflects the latest configuration.
+func (h *Handler) createOrUpdate(w http.ResponseWriter, r *http.Request) {
+	var cfg ProviderConfig
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("could not decode request body: %s", err))
+		return
+	}
+	if cfg.Name == "" || cfg.Type == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name and type are required"))
+		return
+	}
+
+	p, err := build(cfg)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := p.Validate(); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if p.Name() != cfg.Name {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("provider name %q does not match the name %q registered by type %q", cfg.Name, p.Name(), cfg.Type))
+		return
+	}
+
+	h.Chain.Remove(cfg.Name)
+	if err := h.Chain.Add(p, cfg.Enabled, h.AllowInsecure); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	if err := h.Store.CreateOrUpdateProvider(r.Context(), cfg); err != nil {
+		h.Chain.Remove(cfg.Name)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+func (h *Handler) delete(w http.ResponseWriter, r *http.Request, name string) {
+	if err := h.Store.DeleteProvider(r.Context(), name); err != nil {
+		if errors.Is(err, ErrProviderNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	h.Chain.Remove(name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerCreateListGetDelete(t *testing.T) {
+	Register("fake-http-test", func() Provider { return &fakeProvider{name: "http-test"} })
+
+	store := NewMemoryStore()
+	chain := NewChain()
+	h := NewHandler(store, chain)
+
+	body, err := json.Marshal(ProviderConfig{Name: "http-test", Type: "fake-http-test", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/authproviders", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if len(chain.Providers()) != 1 {
+		t.Fatalf("len(chain.Providers()) = %d, want 1", len(chain.Providers()))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/authproviders", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var cfgs []ProviderConfig
+	if err := json.NewDecoder(rec.Body).Decode(&cfgs); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Name != "http-test" {
+		t.Fatalf("cfgs = %+v, want a single http-test entry", cfgs)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/authproviders/http-test", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("get status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	req = httptest.NewRequest(http.MethodDelete, "/authproviders/http-test", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if len(chain.Providers()) != 0 {
+		t.Fatalf("len(chain.Providers()) = %d, want 0 after delete", len(chain.Providers()))
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/authproviders/http-test", nil)
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("get-after-delete status = %d, want %d", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestHandlerRejectsInsecureProviderWithoutAllowInsecure(t *testing.T) {
+	store := NewMemoryStore()
+	chain := NewChain()
+	h := NewHandler(store, chain)
+
+	body, err := json.Marshal(ProviderConfig{Name: "everyone", Type: "allowall-http-test", Enabled: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	Register("allowall-http-test", func() Provider { return &insecureFakeProvider{fakeProvider: &fakeProvider{name: "everyone"}} })
+
+	req := httptest.NewRequest(http.MethodPost, "/authproviders", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusBadRequest, rec.Body.String())
+	}
+	if _, err := store.GetProvider(req.Context(), "everyone"); err == nil {
+		t.Fatal("expected the rejected provider to not be persisted")
+	}
+}
+
+// TestHandlerCreateAllowsMultipleInstancesOfSameType guards against a
+// regression where a provider's Name() ignored the operator-assigned
+// ObjectMeta.Name (set from Spec) and always reported a fixed identity,
+// which silently limited every type to a single named instance.
+func TestHandlerCreateAllowsMultipleInstancesOfSameType(t *testing.T) {
+	Register("fake-multi-instance-test", func() Provider { return &fakeProvider{name: "fake-multi-instance-test"} })
+
+	store := NewMemoryStore()
+	chain := NewChain()
+	h := NewHandler(store, chain)
+
+	for _, name := range []string{"corp-a", "corp-b"} {
+		spec, err := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": name}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := json.Marshal(ProviderConfig{Name: name, Type: "fake-multi-instance-test", Enabled: true, Spec: spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodPost, "/authproviders", bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("create %q status = %d, want %d: %s", name, rec.Code, http.StatusOK, rec.Body.String())
+		}
+	}
+
+	if len(chain.Providers()) != 2 {
+		t.Fatalf("len(chain.Providers()) = %d, want 2", len(chain.Providers()))
+	}
+	if _, err := store.GetProvider(context.Background(), "corp-a"); err != nil {
+		t.Fatalf("corp-a not persisted: %s", err)
+	}
+	if _, err := store.GetProvider(context.Background(), "corp-b"); err != nil {
+		t.Fatalf("corp-b not persisted: %s", err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/authproviders/corp-a", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("delete corp-a status = %d, want %d", rec.Code, http.StatusNoContent)
+	}
+	if len(chain.Providers()) != 1 {
+		t.Fatalf("len(chain.Providers()) = %d after deleting corp-a, want 1", len(chain.Providers()))
+	}
+	if _, err := store.GetProvider(context.Background(), "corp-b"); err != nil {
+		t.Fatalf("corp-b should still be persisted after deleting corp-a: %s", err)
+	}
+}
+
+func TestHandlerLoadAllReconstructsChainFromStore(t *testing.T) {
+	Register("fake-loadall-test", func() Provider { return &fakeProvider{name: "persisted"} })
+
+	store := NewMemoryStore()
+	ctx := context.Background()
+	if err := store.CreateOrUpdateProvider(ctx, ProviderConfig{Name: "persisted", Type: "fake-loadall-test", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	chain := NewChain()
+	h := NewHandler(store, chain)
+	if err := h.LoadAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(chain.Providers()) != 1 {
+		t.Fatalf("len(chain.Providers()) = %d, want 1", len(chain.Providers()))
+	}
+}
@@ -3,19 +3,40 @@ package ldap
 import (
 	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net"
+	"net/url"
+	"strings"
 	"time"
 
 	ldap "github.com/go-ldap/ldap/v3"
 	corev2 "github.com/sensu/sensu-go/api/core/v2"
+	"github.com/sensu/sensu-go/backend/authentication/audit"
 	"github.com/sensu/sensu-go/backend/authentication/jwt"
+	"github.com/sensu/sensu-go/backend/authentication/providers"
 )
 
+// poolErrorReason classifies an error returned from getPool(p).Get(): a
+// *bindError means the service account bind was rejected, anything else is
+// a dial/TLS failure reaching the directory server.
+func poolErrorReason(err error) audit.FailureReason {
+	var be *bindError
+	if errors.As(err, &be) {
+		return audit.FailureBindFailed
+	}
+	return audit.FailureTLSError
+}
+
 // Type represents the type of the basic authentication provider
 const Type = "ldap"
 
+func init() {
+	providers.Register(Type, func() providers.Provider { return &Provider{} })
+}
+
 // Provider represents the allowall internal authentication provider
 type Provider struct {
 	corev2.ObjectMeta `json:"metadata"`
@@ -31,108 +52,285 @@ type Provider struct {
 	GroupAttribute       string // The attribute that is the name of the group
 	GroupClass           string // Group object class
 	GroupUserDNAttribute string // The attribute name the user dn is in for the group
+
+	// TrustedCAs is a PEM encoded CA bundle, or a list of file paths to PEM
+	// encoded CA certificates, used to verify the directory server's
+	// certificate. When empty the host's trusted root CAs are used.
+	TrustedCAs []string
+
+	// ServerName overrides the server name used to verify the certificate
+	// presented by the directory server. Defaults to the host portion of
+	// URL.
+	ServerName string
+
+	// SkipTLSVerify disables verification of the directory server's
+	// certificate. This is insecure and should only be used for testing;
+	// enabling it logs a startup warning.
+	SkipTLSVerify bool
+
+	// UserSearchFilter, when set, overrides the default user search filter.
+	// It must contain at least one "{}" placeholder, which is replaced with
+	// the RFC 4515 escaped username at query time, e.g.
+	// "(&(objectClass=person)(|(uid={})(mail={})))".
+	UserSearchFilter string
+
+	// GroupSearchFilter, when set, overrides the default group search
+	// filter. It must contain at least one "{}" placeholder, which is
+	// replaced with the RFC 4515 escaped user DN at query time.
+	GroupSearchFilter string
+
+	// GroupMembershipFromUserAttribute, when set, names an attribute on the
+	// user entry (commonly "memberOf") whose values are read directly as
+	// the user's group DNs instead of running a second group search.
+	GroupMembershipFromUserAttribute string
+
+	// AuditLogger, when set, receives a structured record of every
+	// Authenticate and Refresh call, success or failure. Left nil, Authenticate
+	// and Refresh behave identically but no audit record is produced; callers
+	// that care about login auditing must assign one themselves.
+	AuditLogger *audit.Logger
+}
+
+// userRecord holds the result of a user lookup: its DN and, when
+// GroupMembershipFromUserAttribute is configured, the raw group membership
+// attribute values read alongside it.
+type userRecord struct {
+	dn       string
+	memberOf []string
 }
 
 var timeout = 2 * time.Second
 
-// Authenticate allow all users to authenticate as god
-func (p *Provider) Authenticate(ctx context.Context, username, password string) (*corev2.Claims, error) {
-	logger.Debugf("Authenticating: %s", username)
-	// Ldap Authenticate the user
-	dn, err := p.getDN(username)
+// tlsConfig builds the *tls.Config used for both StartTLS and native LDAPS
+// dials. When SkipTLSVerify is set certificate validation is disabled and a
+// startup warning is logged; otherwise TrustedCAs (a PEM bundle or a list of
+// file paths to PEM certificates) is used to build the pool of CAs accepted
+// for the directory server's certificate.
+func (p *Provider) tlsConfig() (*tls.Config, error) {
+	serverName := p.ServerName
+	if serverName == "" {
+		if u, err := url.Parse(p.URL); err == nil {
+			serverName = u.Hostname()
+		}
+	}
+	cfg := &tls.Config{ServerName: serverName}
+
+	if p.SkipTLSVerify {
+		logger.Warning("ldap provider configured with SkipTLSVerify: TLS certificate verification is disabled")
+		cfg.InsecureSkipVerify = true
+		return cfg, nil
+	}
+
+	if len(p.TrustedCAs) == 0 {
+		return cfg, nil
+	}
+
+	pool := x509.NewCertPool()
+	for _, ca := range p.TrustedCAs {
+		pem := []byte(ca)
+		if !strings.Contains(ca, "-----BEGIN") {
+			data, err := ioutil.ReadFile(ca)
+			if err != nil {
+				return nil, fmt.Errorf("could not read TrustedCAs file %q: %s", ca, err)
+			}
+			pem = data
+		}
+		if ok := pool.AppendCertsFromPEM(pem); !ok {
+			return nil, fmt.Errorf("no certificates could be parsed from TrustedCAs entry %q", ca)
+		}
+	}
+	cfg.RootCAs = pool
+
+	return cfg, nil
+}
+
+// dial establishes a connection to the directory server, using native LDAPS
+// for ldaps:// URLs and optionally upgrading to TLS via StartTLS otherwise.
+func (p *Provider) dial() (*ldap.Conn, error) {
+	tlsConfig, err := p.tlsConfig()
 	if err != nil {
 		return nil, err
 	}
-	if err := p.validatePassword(dn, password); err != nil {
-		return nil, err
+
+	opts := []ldap.DialOpt{ldap.DialWithDialer(&net.Dialer{Timeout: timeout})}
+	if isLDAPS(p.URL) {
+		opts = append(opts, ldap.DialWithTLSConfig(tlsConfig))
 	}
-	groups, err := p.getGroups(dn)
+
+	l, err := ldap.DialURL(p.URL, opts...)
 	if err != nil {
 		return nil, err
 	}
-	return p.claims(username, groups)
+
+	if p.StartTLS {
+		if err := l.StartTLS(tlsConfig); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+
+	return l, nil
 }
 
-// Takes a username and returns the dn if valid
-func (p *Provider) getDN(username string) (string, error) {
-	logger.Debugf("Getting DN for: %s", username)
-	// Default timeout is 60 seconds.  That is why adjusting it
-	l, err := ldap.DialURL(p.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
-	if err != nil {
-		return "", err
+// isLDAPS returns true when rawURL uses the ldaps:// scheme.
+func isLDAPS(rawURL string) bool {
+	return strings.HasPrefix(rawURL, "ldaps://")
+}
+
+// Conn is the subset of *ldap.Conn used by Provider, extracted so unit tests
+// can substitute a mock directory and so connections can be pooled. *ldap.Conn
+// satisfies this interface.
+type Conn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	StartTLS(config *tls.Config) error
+	Close()
+}
+
+// userFilter returns the user search filter for username, using
+// UserSearchFilter when configured and falling back to the default
+// objectClass/UserAttribute filter otherwise. username is escaped per RFC
+// 4515 before substitution.
+func (p *Provider) userFilter(username string) string {
+	escaped := ldap.EscapeFilter(username)
+	if p.UserSearchFilter == "" {
+		return fmt.Sprintf("(&(objectClass=%s)(%s=%s))", p.UserClass, p.UserAttribute, escaped)
 	}
-	defer l.Close()
+	return strings.Replace(p.UserSearchFilter, "{}", escaped, -1)
+}
 
-	// Reconnect with TLS
-	if p.StartTLS {
-		if err := l.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
-			return "", err
+// groupFilter returns the group search filter for userdn, using
+// GroupSearchFilter when configured and falling back to the default
+// objectClass/GroupUserDNAttribute filter otherwise. userdn is escaped per
+// RFC 4515 before substitution.
+func (p *Provider) groupFilter(userdn string) string {
+	escaped := ldap.EscapeFilter(userdn)
+	if p.GroupSearchFilter == "" {
+		return fmt.Sprintf("(&(objectClass=%s)(%s=%s))", p.GroupClass, p.GroupUserDNAttribute, escaped)
+	}
+	return strings.Replace(p.GroupSearchFilter, "{}", escaped, -1)
+}
+
+// Authenticate looks up username, verifies password by binding as the user,
+// and returns claims carrying the user's groups
+func (p *Provider) Authenticate(ctx context.Context, username, password string) (claims *corev2.Claims, err error) {
+	var groups []string
+	reason := audit.FailureNone
+	defer func() {
+		p.auditRecord(ctx, username, err == nil, reason, groups)
+	}()
+
+	conn, err := getPool(p).Get()
+	if err != nil {
+		reason = poolErrorReason(err)
+		return nil, err
+	}
+	returned := false
+	defer func() {
+		if !returned {
+			conn.Close()
 		}
+	}()
+
+	user, err := p.getDN(conn, username)
+	if err != nil {
+		reason = audit.FailureUserNotFound
+		return nil, err
 	}
+
+	// Bind as the user to verify their password
+	if err := conn.Bind(user.dn, password); err != nil {
+		reason = audit.FailureBindFailed
+		return nil, err
+	}
+
+	groups, err = p.getGroups(conn, user)
+	if err != nil {
+		reason = audit.FailureGroupLookupFailed
+		return nil, err
+	}
+
+	// Rebind as the service account before returning the connection to the
+	// pool so the next borrower finds it in a known state.
+	if err := conn.Bind(p.BindUsername, p.BindPassword); err == nil {
+		getPool(p).Put(conn)
+		returned = true
+	}
+
+	claims, err = p.claims(username, groups)
+	if err != nil {
+		reason = audit.FailureOther
+	}
+	return claims, err
+}
+
+// auditRecord sends a structured audit record for an Authenticate or
+// Refresh attempt to p.AuditLogger, if one is configured.
+func (p *Provider) auditRecord(ctx context.Context, username string, success bool, reason audit.FailureReason, groups []string) {
+	p.AuditLogger.Record(ctx, p.Name(), username, success, reason, groups)
+}
+
+// Takes a username and returns the matching userRecord if valid
+func (p *Provider) getDN(conn Conn, username string) (userRecord, error) {
+	logger.Debugf("Getting DN for: %s", username)
+
 	// First bind with a read only user
-	if err = l.Bind(p.BindUsername, p.BindPassword); err != nil {
-		return "", err
+	if err := conn.Bind(p.BindUsername, p.BindPassword); err != nil {
+		return userRecord{}, err
+	}
+
+	attributes := []string{"dn"}
+	if p.GroupMembershipFromUserAttribute != "" {
+		attributes = append(attributes, p.GroupMembershipFromUserAttribute)
 	}
+
 	// Search for the given username
 	searchRequest := ldap.NewSearchRequest(
 		p.UserBaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf("(&(objectClass=%s)(%s=%s))", p.UserClass, p.UserAttribute, username),
-		[]string{"dn"},
+		p.userFilter(username),
+		attributes,
 		nil,
 	)
 
-	sr, err := l.Search(searchRequest)
+	sr, err := conn.Search(searchRequest)
 	if err != nil {
-		return "", err
+		return userRecord{}, err
 	}
 
 	if len(sr.Entries) != 1 {
-		return "", errors.New("User does not exist or too many entries returned")
+		return userRecord{}, errors.New("User does not exist or too many entries returned")
 	}
-	userdn := sr.Entries[0].DN
+	entry := sr.Entries[0]
 
-	return userdn, nil
-}
-func (p *Provider) validatePassword(dn string, password string) error {
-	logger.Debugf("Validating password for: %s", dn)
-	// Default timeout is 60 seconds.  That is why adjusting it
-	l, err := ldap.DialURL(p.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
-	if err != nil {
-		return err
+	user := userRecord{dn: entry.DN}
+	if p.GroupMembershipFromUserAttribute != "" {
+		user.memberOf = entry.GetAttributeValues(p.GroupMembershipFromUserAttribute)
 	}
-	defer l.Close()
 
-	// Reconnect with TLS
-	if p.StartTLS {
-		if err := l.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
-			return err
-		}
-	}
-	// Bind as the user to verify their password
-	if err := l.Bind(dn, password); err != nil {
-		return err
-	}
-	return nil
+	return user, nil
 }
-func (p *Provider) getGroups(userdn string) ([]string, error) {
-	logger.Debugf("Getting groups for: %s", userdn)
-	// Default timeout is 60 seconds.  That is why adjusting it
-	l, err := ldap.DialURL(p.URL, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}))
-	if err != nil {
-		return nil, err
-	}
-	defer l.Close()
 
-	// Reconnect with TLS
-	if p.StartTLS {
-		if err := l.StartTLS(&tls.Config{InsecureSkipVerify: true}); err != nil {
-			return nil, err
+// getGroups resolves the groups a user belongs to. When
+// GroupMembershipFromUserAttribute is configured, the membership already
+// read alongside the user entry is used directly, common-name per DN,
+// avoiding a second search. Otherwise a group search is performed using
+// GroupSearchFilter (or the default objectClass/GroupUserDNAttribute
+// filter).
+func (p *Provider) getGroups(conn Conn, user userRecord) ([]string, error) {
+	if p.GroupMembershipFromUserAttribute != "" {
+		groups := make([]string, 0, len(user.memberOf))
+		for _, dn := range user.memberOf {
+			groups = append(groups, groupNameFromDN(dn))
 		}
+		logger.Debugf("%s is a member of: %s", user.dn, groups)
+		return groups, nil
 	}
 
+	logger.Debugf("Getting groups for: %s", user.dn)
+
 	// First bind with a read only user
-	if err = l.Bind(p.BindUsername, p.BindPassword); err != nil {
+	if err := conn.Bind(p.BindUsername, p.BindPassword); err != nil {
 		return nil, err
 	}
 
@@ -140,12 +338,12 @@ func (p *Provider) getGroups(userdn string) ([]string, error) {
 	searchRequest := ldap.NewSearchRequest(
 		p.GroupBaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf("(&(objectClass=%s)(%s=%s))", p.GroupClass, p.GroupUserDNAttribute, userdn),
+		p.groupFilter(user.dn),
 		[]string{p.GroupAttribute},
 		nil,
 	)
 
-	sr, err := l.Search(searchRequest)
+	sr, err := conn.Search(searchRequest)
 	if err != nil {
 		logger.Debugf("Group search base dn: %s", searchRequest.BaseDN)
 		logger.Debugf("Group search filter: %s", searchRequest.Filter)
@@ -155,22 +353,61 @@ func (p *Provider) getGroups(userdn string) ([]string, error) {
 	for _, entry := range sr.Entries {
 		groups = append(groups, entry.GetAttributeValue(p.GroupAttribute))
 	}
-	logger.Debugf("%s is a member of: %s", userdn, groups)
+	logger.Debugf("%s is a member of: %s", user.dn, groups)
 	return groups, nil
 }
 
+// groupNameFromDN returns the value of the first RDN attribute of dn (e.g.
+// "cn" in "cn=Admins,ou=Groups,dc=example,dc=com"), or dn itself if it
+// cannot be parsed.
+func groupNameFromDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
+}
+
 // Refresh renews the user claims with the provider claims
-func (p *Provider) Refresh(ctx context.Context, claims *corev2.Claims) (*corev2.Claims, error) {
-	logger.Debugf("Refreshing: %s", claims.Provider.UserID)
-	dn, err := p.getDN(claims.Provider.UserID)
+func (p *Provider) Refresh(ctx context.Context, claims *corev2.Claims) (renewed *corev2.Claims, err error) {
+	username := claims.Provider.UserID
+	var groups []string
+	reason := audit.FailureNone
+	defer func() {
+		p.auditRecord(ctx, username, err == nil, reason, groups)
+	}()
+
+	conn, err := getPool(p).Get()
 	if err != nil {
+		reason = poolErrorReason(err)
 		return nil, err
 	}
-	groups, err := p.getGroups(dn)
+	returned := false
+	defer func() {
+		if !returned {
+			conn.Close()
+		}
+	}()
+
+	user, err := p.getDN(conn, username)
+	if err != nil {
+		reason = audit.FailureUserNotFound
+		return nil, err
+	}
+	groups, err = p.getGroups(conn, user)
 	if err != nil {
+		reason = audit.FailureGroupLookupFailed
 		return nil, err
 	}
-	return p.claims(claims.Provider.UserID, groups)
+
+	getPool(p).Put(conn)
+	returned = true
+
+	renewed, err = p.claims(username, groups)
+	if err != nil {
+		reason = audit.FailureOther
+	}
+	return renewed, err
 }
 
 func (p *Provider) claims(username string, groups []string) (*corev2.Claims, error) {
@@ -187,8 +424,13 @@ func (p *Provider) claims(username string, groups []string) (*corev2.Claims, err
 	return claims, err
 }
 
-// Name returns the provider name
+// Name returns the operator-assigned name of this provider instance,
+// falling back to Type when none was set. This lets multiple ldap providers
+// (e.g. two separate domains) coexist in a Chain under distinct names.
 func (p *Provider) Name() string {
+	if p.ObjectMeta.Name != "" {
+		return p.ObjectMeta.Name
+	}
 	return Type
 }
 
@@ -229,6 +471,17 @@ func (p *Provider) URIPath() string {
 
 // Validate checks if the fields in the resource are valid.
 func (p *Provider) Validate() error {
-	p.ObjectMeta.Name = Type
+	if p.ObjectMeta.Name == "" {
+		p.ObjectMeta.Name = Type
+	}
+	if _, err := p.tlsConfig(); err != nil {
+		return fmt.Errorf("invalid ldap provider TLS configuration: %s", err)
+	}
+	if p.UserSearchFilter != "" && !strings.Contains(p.UserSearchFilter, "{}") {
+		return errors.New("UserSearchFilter must contain a \"{}\" placeholder")
+	}
+	if p.GroupSearchFilter != "" && !strings.Contains(p.GroupSearchFilter, "{}") {
+		return errors.New("GroupSearchFilter must contain a \"{}\" placeholder")
+	}
 	return nil
 }
@@ -0,0 +1,342 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/sensu/sensu-go/backend/authentication/audit"
+)
+
+// mockConn is a minimal Conn used to unit test Provider without a live
+// directory server.
+type mockConn struct {
+	bindErr   error
+	searchErr error
+	entries   []*ldap.Entry
+}
+
+func (m *mockConn) Bind(username, password string) error {
+	return m.bindErr
+}
+
+func (m *mockConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	if m.searchErr != nil {
+		return nil, m.searchErr
+	}
+	return &ldap.SearchResult{Entries: m.entries}, nil
+}
+
+func (m *mockConn) StartTLS(config *tls.Config) error {
+	return nil
+}
+
+func (m *mockConn) Close() {}
+
+const testCert = `-----BEGIN CERTIFICATE-----
+MIIBjzCCATWgAwIBAgIUUNcNIIM3B6tS1MioK4awSbUt7zswCgYIKoZIzj0EAwIw
+EjEQMA4GA1UECgwHQWNtZSBDbzAeFw0yNjA3MjYyMDIzMThaFw0zNjA3MjMyMDIz
+MThaMBIxEDAOBgNVBAoMB0FjbWUgQ28wWTATBgcqhkjOPQIBBggqhkjOPQMBBwNC
+AASvOcdgyD+Fqr1FVuPAq4/Pnerv/sAaerTHuo08gQHuA8BJaI+qHRNBallIiulC
+ogSrTmcvHQvB3hNB4Bps22Xzo2kwZzAdBgNVHQ4EFgQUMra5JvQckZrACkeppCrf
+715hUdgwHwYDVR0jBBgwFoAUMra5JvQckZrACkeppCrf715hUdgwDwYDVR0TAQH/
+BAUwAwEB/zAUBgNVHREEDTALgglsb2NhbGhvc3QwCgYIKoZIzj0EAwIDSAAwRQIh
+AIdNp+V1ITjbjCuKNK7DjBrgpN67iYHw5dFh6gpSvM1lAiAhfoKtF+N8WMeuV96i
+++m1eLpQ3ZFWBTB0g3wunRFQBA==
+-----END CERTIFICATE-----
+`
+
+func TestProviderTLSConfig(t *testing.T) {
+	caFile, err := ioutil.TempFile("", "ldap-ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(caFile.Name())
+	if _, err := caFile.WriteString(testCert); err != nil {
+		t.Fatal(err)
+	}
+	caFile.Close()
+
+	tests := []struct {
+		name    string
+		p       Provider
+		wantErr bool
+	}{
+		{
+			name: "no trusted CAs uses system pool",
+			p:    Provider{},
+		},
+		{
+			name: "inline PEM bundle",
+			p:    Provider{TrustedCAs: []string{testCert}},
+		},
+		{
+			name: "CA file path",
+			p:    Provider{TrustedCAs: []string{caFile.Name()}},
+		},
+		{
+			name: "skip verify bypasses TrustedCAs",
+			p:    Provider{SkipTLSVerify: true, TrustedCAs: []string{"not a certificate"}},
+		},
+		{
+			name:    "missing CA file",
+			p:       Provider{TrustedCAs: []string{"/no/such/file.pem"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid PEM data",
+			p:       Provider{TrustedCAs: []string{"not a certificate"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, err := tc.p.tlsConfig()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected non-nil error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.InsecureSkipVerify != tc.p.SkipTLSVerify {
+				t.Errorf("InsecureSkipVerify = %v, want %v", cfg.InsecureSkipVerify, tc.p.SkipTLSVerify)
+			}
+		})
+	}
+}
+
+func TestIsLDAPS(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{url: "ldaps://dc.example.com:636", want: true},
+		{url: "ldap://dc.example.com:389", want: false},
+		{url: "", want: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.url, func(t *testing.T) {
+			if got := isLDAPS(tc.url); got != tc.want {
+				t.Errorf("isLDAPS(%q) = %v, want %v", tc.url, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProviderUserFilter(t *testing.T) {
+	tests := []struct {
+		name     string
+		p        Provider
+		username string
+		want     string
+	}{
+		{
+			name:     "default filter",
+			p:        Provider{UserClass: "person", UserAttribute: "uid"},
+			username: "eric",
+			want:     "(&(objectClass=person)(uid=eric))",
+		},
+		{
+			name:     "custom template",
+			p:        Provider{UserSearchFilter: "(&(objectClass=person)(|(uid={})(mail={})))"},
+			username: "eric",
+			want:     "(&(objectClass=person)(|(uid=eric)(mail=eric)))",
+		},
+		{
+			name:     "username is escaped",
+			p:        Provider{UserSearchFilter: "(uid={})"},
+			username: "eric)(uid=*",
+			want:     `(uid=eric\29\28uid=\2a)`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.userFilter(tc.username); got != tc.want {
+				t.Errorf("userFilter() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProviderGroupFilter(t *testing.T) {
+	tests := []struct {
+		name string
+		p    Provider
+		dn   string
+		want string
+	}{
+		{
+			name: "default filter",
+			p:    Provider{GroupClass: "group", GroupUserDNAttribute: "member"},
+			dn:   "cn=eric,dc=example,dc=com",
+			want: "(&(objectClass=group)(member=cn=eric,dc=example,dc=com))",
+		},
+		{
+			name: "custom template",
+			p:    Provider{GroupSearchFilter: "(member={})"},
+			dn:   "cn=eric,dc=example,dc=com",
+			want: "(member=cn=eric,dc=example,dc=com)",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.p.groupFilter(tc.dn); got != tc.want {
+				t.Errorf("groupFilter() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestProviderValidateFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		p       Provider
+		wantErr bool
+	}{
+		{name: "empty filters are fine", p: Provider{}},
+		{name: "valid user filter", p: Provider{UserSearchFilter: "(uid={})"}},
+		{name: "valid group filter", p: Provider{GroupSearchFilter: "(member={})"}},
+		{name: "user filter missing placeholder", p: Provider{UserSearchFilter: "(uid=eric)"}, wantErr: true},
+		{name: "group filter missing placeholder", p: Provider{GroupSearchFilter: "(member=eric)"}, wantErr: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.p.Validate()
+			if tc.wantErr && err == nil {
+				t.Fatal("expected non-nil error")
+			}
+			if !tc.wantErr && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestProviderGetDN(t *testing.T) {
+	p := Provider{UserBaseDN: "dc=example,dc=com"}
+
+	conn := &mockConn{entries: []*ldap.Entry{{DN: "cn=eric,dc=example,dc=com"}}}
+	user, err := p.getDN(conn, "eric")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.dn != "cn=eric,dc=example,dc=com" {
+		t.Errorf("getDN() dn = %q, want %q", user.dn, "cn=eric,dc=example,dc=com")
+	}
+
+	if _, err := p.getDN(&mockConn{entries: nil}, "eric"); err == nil {
+		t.Fatal("expected error when no entries are returned")
+	}
+
+	if _, err := p.getDN(&mockConn{searchErr: errors.New("boom")}, "eric"); err == nil {
+		t.Fatal("expected search error to propagate")
+	}
+}
+
+func TestProviderGetGroupsFromMemberOf(t *testing.T) {
+	p := Provider{GroupMembershipFromUserAttribute: "memberOf"}
+	user := userRecord{
+		dn: "cn=eric,dc=example,dc=com",
+		memberOf: []string{
+			"cn=Admins,ou=Groups,dc=example,dc=com",
+			"cn=Operators,ou=Groups,dc=example,dc=com",
+		},
+	}
+
+	groups, err := p.getGroups(nil, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Admins", "Operators"}
+	if len(groups) != len(want) {
+		t.Fatalf("getGroups() = %v, want %v", groups, want)
+	}
+	for i := range want {
+		if groups[i] != want[i] {
+			t.Errorf("getGroups()[%d] = %q, want %q", i, groups[i], want[i])
+		}
+	}
+}
+
+func TestProviderGetGroupsSearch(t *testing.T) {
+	p := Provider{GroupAttribute: "cn"}
+	entry := ldap.NewEntry("cn=Admins,ou=Groups,dc=example,dc=com", map[string][]string{
+		"cn": {"Admins"},
+	})
+	conn := &mockConn{entries: []*ldap.Entry{entry}}
+
+	groups, err := p.getGroups(conn, userRecord{dn: "cn=eric,dc=example,dc=com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 1 || groups[0] != "Admins" {
+		t.Errorf("getGroups() = %v, want [Admins]", groups)
+	}
+}
+
+func TestProviderNameDefaultsToTypeButHonorsObjectMeta(t *testing.T) {
+	unnamed := Provider{}
+	if err := unnamed.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if unnamed.Name() != Type {
+		t.Errorf("Name() = %q, want %q", unnamed.Name(), Type)
+	}
+
+	named := Provider{}
+	named.ObjectMeta.Name = "ldap-corp"
+	if err := named.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if named.Name() != "ldap-corp" {
+		t.Errorf("Name() = %q, want %q; Validate must not overwrite an operator-assigned name", named.Name(), "ldap-corp")
+	}
+}
+
+func TestGroupNameFromDN(t *testing.T) {
+	tests := []struct {
+		dn   string
+		want string
+	}{
+		{dn: "cn=Admins,ou=Groups,dc=example,dc=com", want: "Admins"},
+		{dn: "not a dn", want: "not a dn"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.dn, func(t *testing.T) {
+			if got := groupNameFromDN(tc.dn); got != tc.want {
+				t.Errorf("groupNameFromDN(%q) = %q, want %q", tc.dn, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPoolErrorReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want audit.FailureReason
+	}{
+		{name: "service account bind rejected", err: &bindError{err: errors.New("invalid credentials")}, want: audit.FailureBindFailed},
+		{name: "dial failure", err: errors.New("connection refused"), want: audit.FailureTLSError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := poolErrorReason(tc.err); got != tc.want {
+				t.Errorf("poolErrorReason() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package ldap
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// defaultMaxIdle is the number of idle, service-account-bound connections
+// kept per directory/credential pair.
+const defaultMaxIdle = 8
+
+// defaultIdleTimeout is how long an idle connection may sit in the pool
+// before it is considered stale and closed instead of reused.
+const defaultIdleTimeout = 1 * time.Minute
+
+// pools holds one connPool per distinct URL+bind-credentials pair, so a
+// burst of logins against the same directory reuses connections instead of
+// dialing a fresh one per request.
+var pools sync.Map // map[string]*connPool
+
+// getPool returns the connPool for p, creating one on first use.
+func getPool(p *Provider) *connPool {
+	key := poolKey(p)
+	if existing, ok := pools.Load(key); ok {
+		return existing.(*connPool)
+	}
+	created, _ := pools.LoadOrStore(key, &connPool{provider: p})
+	return created.(*connPool)
+}
+
+// poolKey identifies connections that can be safely shared: the same
+// directory URL bound with the same service account. The bind password is
+// hashed so it never appears in the key itself.
+func poolKey(p *Provider) string {
+	sum := sha256.Sum256([]byte(p.BindPassword))
+	return fmt.Sprintf("%s|%s|%s", p.URL, p.BindUsername, hex.EncodeToString(sum[:]))
+}
+
+// idleConn is a pooled connection along with the time it was released.
+type idleConn struct {
+	conn     Conn
+	returned time.Time
+}
+
+// connPool is a small, bounded pool of connections already bound as the
+// service account, keyed by directory URL and bind credentials. It exists
+// so a burst of agent/API logins does not open a new TCP session to the
+// directory per request.
+type connPool struct {
+	mu          sync.Mutex
+	provider    *Provider
+	idle        []idleConn
+	maxIdle     int
+	idleTimeout time.Duration
+}
+
+// bindError wraps an error encountered while binding as the service
+// account, distinguishing it from a dial/TLS failure so callers can
+// classify the two differently (e.g. in an audit log).
+type bindError struct {
+	err error
+}
+
+func (e *bindError) Error() string { return e.err.Error() }
+
+// Unwrap allows errors.Is/As to see the underlying cause.
+func (e *bindError) Unwrap() error { return e.err }
+
+// Get returns a connection bound as the service account, reusing a healthy
+// idle connection when one is available and dialing a new one otherwise. A
+// failure to bind as the service account is returned wrapped in a
+// *bindError, to be distinguished from a dial/TLS failure.
+func (pl *connPool) Get() (Conn, error) {
+	if conn := pl.takeIdle(); conn != nil {
+		return conn, nil
+	}
+
+	conn, err := pl.provider.dial()
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.Bind(pl.provider.BindUsername, pl.provider.BindPassword); err != nil {
+		conn.Close()
+		return nil, &bindError{err: err}
+	}
+	return conn, nil
+}
+
+// takeIdle pops the most recently returned, non-stale idle connection, if
+// any, discarding any connections that have exceeded the idle timeout along
+// the way.
+func (pl *connPool) takeIdle() Conn {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	timeout := pl.idleTimeout
+	if timeout == 0 {
+		timeout = defaultIdleTimeout
+	}
+
+	for len(pl.idle) > 0 {
+		last := len(pl.idle) - 1
+		entry := pl.idle[last]
+		pl.idle = pl.idle[:last]
+		if time.Since(entry.returned) > timeout {
+			entry.conn.Close()
+			continue
+		}
+		return entry.conn
+	}
+	return nil
+}
+
+// Put returns conn to the pool for reuse, health-checking it first by
+// rebinding as the service account. A connection that fails the health
+// check, or that would exceed the pool's idle capacity, is closed instead.
+func (pl *connPool) Put(conn Conn) {
+	if err := conn.Bind(pl.provider.BindUsername, pl.provider.BindPassword); err != nil {
+		logger.Debugf("closing unhealthy ldap connection: %s", err)
+		conn.Close()
+		return
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	maxIdle := pl.maxIdle
+	if maxIdle == 0 {
+		maxIdle = defaultMaxIdle
+	}
+	if len(pl.idle) >= maxIdle {
+		conn.Close()
+		return
+	}
+	pl.idle = append(pl.idle, idleConn{conn: conn, returned: time.Now()})
+}
+
+// ConnectionError describes a failure encountered while testing
+// connectivity to the directory server, identifying the stage at which it
+// occurred so it can be surfaced as a structured diagnostic.
+type ConnectionError struct {
+	// Stage is the step that failed: "dial" or "bind".
+	Stage string
+	URL   string
+	Err   error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("ldap connection test failed during %s against %s: %s", e.Stage, e.URL, e.Err)
+}
+
+// Unwrap allows errors.Is/As to see the underlying cause.
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// TestConnection dials the directory server and binds with the configured
+// service account, returning a structured ConnectionError describing what
+// failed and at which stage. It does not use the connection pool, since its
+// purpose is to validate configuration rather than to serve a login.
+func (p *Provider) TestConnection(ctx context.Context) error {
+	conn, err := p.dial()
+	if err != nil {
+		return &ConnectionError{Stage: "dial", URL: p.URL, Err: err}
+	}
+	defer conn.Close()
+
+	if err := conn.Bind(p.BindUsername, p.BindPassword); err != nil {
+		return &ConnectionError{Stage: "bind", URL: p.URL, Err: err}
+	}
+
+	return nil
+}
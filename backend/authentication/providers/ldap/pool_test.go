@@ -0,0 +1,87 @@
+package ldap
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConnPoolReusesIdleConnections(t *testing.T) {
+	pl := &connPool{provider: &Provider{}}
+	conn := &mockConn{}
+
+	pl.Put(conn)
+	got, err := pl.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != Conn(conn) {
+		t.Error("Get() did not return the connection Put() into the pool")
+	}
+}
+
+func TestConnPoolDiscardsUnhealthyConnection(t *testing.T) {
+	pl := &connPool{provider: &Provider{}}
+	conn := &mockConn{bindErr: errors.New("directory is down")}
+
+	pl.Put(conn)
+	if len(pl.idle) != 0 {
+		t.Fatal("unhealthy connection should not be kept idle")
+	}
+}
+
+func TestConnPoolDiscardsStaleConnection(t *testing.T) {
+	pl := &connPool{provider: &Provider{}, idleTimeout: time.Millisecond}
+	pl.idle = []idleConn{{conn: &mockConn{}, returned: time.Now().Add(-time.Hour)}}
+
+	if got := pl.takeIdle(); got != nil {
+		t.Error("expected stale connection to be discarded, not returned")
+	}
+}
+
+func TestConnPoolRespectsMaxIdle(t *testing.T) {
+	pl := &connPool{provider: &Provider{}, maxIdle: 1}
+
+	pl.Put(&mockConn{})
+	pl.Put(&mockConn{})
+
+	if len(pl.idle) != 1 {
+		t.Errorf("len(idle) = %d, want 1", len(pl.idle))
+	}
+}
+
+func TestPoolKeyDiffersByCredentials(t *testing.T) {
+	a := poolKey(&Provider{URL: "ldap://dc", BindUsername: "svc", BindPassword: "hunter2"})
+	b := poolKey(&Provider{URL: "ldap://dc", BindUsername: "svc", BindPassword: "other"})
+	if a == b {
+		t.Error("poolKey() should differ when bind passwords differ")
+	}
+}
+
+func TestBindErrorUnwraps(t *testing.T) {
+	cause := errors.New("invalid credentials")
+	err := &bindError{err: cause}
+
+	if err.Error() != cause.Error() {
+		t.Errorf("Error() = %q, want %q", err.Error(), cause.Error())
+	}
+	if !errors.Is(err, cause) {
+		t.Error("expected errors.Is(err, cause) to be true via Unwrap")
+	}
+}
+
+func TestProviderTestConnection(t *testing.T) {
+	p := Provider{URL: "ldap://127.0.0.1:1"}
+	err := p.TestConnection(context.Background())
+	if err == nil {
+		t.Fatal("expected an error dialing an unreachable directory")
+	}
+	var connErr *ConnectionError
+	if !errors.As(err, &connErr) {
+		t.Fatalf("expected a *ConnectionError, got %T", err)
+	}
+	if connErr.Stage != "dial" {
+		t.Errorf("Stage = %q, want %q", connErr.Stage, "dial")
+	}
+}
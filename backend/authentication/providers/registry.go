@@ -0,0 +1,70 @@
+// Package providers defines the pluggable authentication provider registry.
+// Concrete providers (allowall, ldap, activedirectory, ...) register a
+// factory for their type from their own init() function, so the backend can
+// construct and chain them by type name without importing every provider
+// package directly.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+// Provider is implemented by every authentication provider. It is the
+// common shape already shared by allowall.Provider and ldap.Provider.
+type Provider interface {
+	Authenticate(ctx context.Context, username, password string) (*corev2.Claims, error)
+	Refresh(ctx context.Context, claims *corev2.Claims) (*corev2.Claims, error)
+	Name() string
+	Type() string
+	GetObjectMeta() corev2.ObjectMeta
+	SetObjectMeta(corev2.ObjectMeta)
+	SetNamespace(string)
+	StorePrefix() string
+	RBACName() string
+	URIPath() string
+	Validate() error
+}
+
+// Factory constructs a new, zero-valued Provider instance for a registered
+// type, ready to be unmarshaled from stored or submitted configuration.
+type Factory func() Provider
+
+var (
+	mu       sync.Mutex
+	registry = map[string]Factory{}
+)
+
+// Register adds factory to the registry under providerType. It is intended
+// to be called once, from a provider package's init() function.
+func Register(providerType string, factory Factory) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[providerType] = factory
+}
+
+// New constructs a new Provider instance for providerType, returning an
+// error if no provider has registered under that type.
+func New(providerType string) (Provider, error) {
+	mu.Lock()
+	factory, ok := registry[providerType]
+	mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown authentication provider type %q", providerType)
+	}
+	return factory(), nil
+}
+
+// Types returns the provider types currently registered.
+func Types() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	return types
+}
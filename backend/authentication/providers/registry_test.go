@@ -0,0 +1,67 @@
+package providers
+
+import (
+	"context"
+	"testing"
+
+	corev2 "github.com/sensu/sensu-go/api/core/v2"
+)
+
+// fakeProvider is a minimal Provider used to exercise the registry and
+// chain without depending on a concrete provider package.
+type fakeProvider struct {
+	corev2.ObjectMeta `json:"metadata"`
+	name              string
+	authErr           error
+}
+
+func (p *fakeProvider) Authenticate(ctx context.Context, username, password string) (*corev2.Claims, error) {
+	if p.authErr != nil {
+		return nil, p.authErr
+	}
+	return &corev2.Claims{Provider: corev2.AuthProviderClaims{ProviderID: p.name, UserID: username}}, nil
+}
+
+func (p *fakeProvider) Refresh(ctx context.Context, claims *corev2.Claims) (*corev2.Claims, error) {
+	return claims, nil
+}
+
+// Name returns the operator-assigned ObjectMeta.Name when set, falling back
+// to the name the factory constructed this instance with. This mirrors the
+// real providers (ldap, activedirectory), letting tests exercise multiple
+// same-type instances distinguished only by ObjectMeta.Name.
+func (p *fakeProvider) Name() string {
+	if p.ObjectMeta.Name != "" {
+		return p.ObjectMeta.Name
+	}
+	return p.name
+}
+func (p *fakeProvider) Type() string                         { return "fake" }
+func (p *fakeProvider) GetObjectMeta() corev2.ObjectMeta     { return p.ObjectMeta }
+func (p *fakeProvider) SetObjectMeta(meta corev2.ObjectMeta) { p.ObjectMeta = meta }
+func (p *fakeProvider) SetNamespace(namespace string)        { p.Namespace = namespace }
+func (p *fakeProvider) StorePrefix() string                  { return "" }
+func (p *fakeProvider) RBACName() string                     { return "" }
+func (p *fakeProvider) URIPath() string                      { return "" }
+func (p *fakeProvider) Validate() error {
+	if p.ObjectMeta.Name == "" {
+		p.ObjectMeta.Name = p.name
+	}
+	return nil
+}
+
+func TestRegisterAndNew(t *testing.T) {
+	Register("fake-registry-test", func() Provider { return &fakeProvider{name: "fake-registry-test"} })
+
+	p, err := New("fake-registry-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name() != "fake-registry-test" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "fake-registry-test")
+	}
+
+	if _, err := New("does-not-exist"); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+}
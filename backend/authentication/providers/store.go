@@ -0,0 +1,103 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ProviderConfig is the persisted, wire representation of a single
+// configured authentication provider: its registered type, its unique name,
+// whether it is currently enabled, and its type-specific configuration,
+// stored as the provider's own JSON encoding so it can be unmarshaled back
+// into a concrete Provider constructed via New(Type).
+type ProviderConfig struct {
+	Name    string          `json:"name"`
+	Type    string          `json:"type"`
+	Enabled bool            `json:"enabled"`
+	Spec    json.RawMessage `json:"spec"`
+}
+
+// Store persists ProviderConfigs so that providers added, updated, or
+// removed at runtime survive a backend restart.
+type Store interface {
+	CreateOrUpdateProvider(ctx context.Context, cfg ProviderConfig) error
+	GetProvider(ctx context.Context, name string) (ProviderConfig, error)
+	ListProviders(ctx context.Context) ([]ProviderConfig, error)
+	DeleteProvider(ctx context.Context, name string) error
+}
+
+// ErrProviderNotFound is returned by Store implementations when no
+// ProviderConfig is stored under the requested name.
+var ErrProviderNotFound = fmt.Errorf("authentication provider not found")
+
+// MemoryStore is a Store backed by a map, guarded by a mutex. It is the
+// reference Store implementation used when no durable backend store is
+// wired in; production deployments should back Store with the backend's own
+// persistent store instead.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	configs map[string]ProviderConfig
+}
+
+// NewMemoryStore returns an empty, ready to use MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{configs: map[string]ProviderConfig{}}
+}
+
+// CreateOrUpdateProvider implements Store.
+func (s *MemoryStore) CreateOrUpdateProvider(ctx context.Context, cfg ProviderConfig) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.configs[cfg.Name] = cfg
+	return nil
+}
+
+// GetProvider implements Store.
+func (s *MemoryStore) GetProvider(ctx context.Context, name string) (ProviderConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfg, ok := s.configs[name]
+	if !ok {
+		return ProviderConfig{}, ErrProviderNotFound
+	}
+	return cfg, nil
+}
+
+// ListProviders implements Store.
+func (s *MemoryStore) ListProviders(ctx context.Context) ([]ProviderConfig, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cfgs := make([]ProviderConfig, 0, len(s.configs))
+	for _, cfg := range s.configs {
+		cfgs = append(cfgs, cfg)
+	}
+	return cfgs, nil
+}
+
+// DeleteProvider implements Store.
+func (s *MemoryStore) DeleteProvider(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.configs[name]; !ok {
+		return ErrProviderNotFound
+	}
+	delete(s.configs, name)
+	return nil
+}
+
+// build constructs a Provider from cfg by looking up its registered type and
+// unmarshaling its Spec into the resulting zero-valued Provider.
+func build(cfg ProviderConfig) (Provider, error) {
+	p, err := New(cfg.Type)
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Spec) > 0 {
+		if err := json.Unmarshal(cfg.Spec, p); err != nil {
+			return nil, fmt.Errorf("could not unmarshal provider %q configuration: %s", cfg.Name, err)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMemoryStoreCRUD(t *testing.T) {
+	s := NewMemoryStore()
+	ctx := context.Background()
+
+	if _, err := s.GetProvider(ctx, "missing"); !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("err = %v, want ErrProviderNotFound", err)
+	}
+
+	cfg := ProviderConfig{Name: "fake-store-test", Type: "fake-registry-test", Enabled: true}
+	if err := s.CreateOrUpdateProvider(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := s.GetProvider(ctx, cfg.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != cfg.Name || !got.Enabled {
+		t.Errorf("got = %+v, want %+v", got, cfg)
+	}
+
+	cfg.Enabled = false
+	if err := s.CreateOrUpdateProvider(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	got, err = s.GetProvider(ctx, cfg.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Enabled {
+		t.Error("Enabled = true, want false after update")
+	}
+
+	cfgs, err := s.ListProviders(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfgs) != 1 {
+		t.Fatalf("len(cfgs) = %d, want 1", len(cfgs))
+	}
+
+	if err := s.DeleteProvider(ctx, cfg.Name); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteProvider(ctx, cfg.Name); !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("err = %v, want ErrProviderNotFound", err)
+	}
+}
+
+func TestBuildUnmarshalsSpecIntoRegisteredType(t *testing.T) {
+	Register("fake-build-test", func() Provider { return &fakeProvider{} })
+
+	spec, err := json.Marshal(map[string]interface{}{"metadata": map[string]string{"name": "built"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := build(ProviderConfig{Name: "built", Type: "fake-build-test", Spec: spec})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Type() != "fake" {
+		t.Errorf("Type() = %q, want %q", p.Type(), "fake")
+	}
+	if p.Name() != "built" {
+		t.Errorf("Name() = %q, want %q", p.Name(), "built")
+	}
+}
+
+func TestBuildUnknownTypeErrors(t *testing.T) {
+	if _, err := build(ProviderConfig{Name: "x", Type: "does-not-exist"}); err == nil {
+		t.Fatal("expected an error for an unregistered type")
+	}
+}